@@ -0,0 +1,50 @@
+// Package builtins holds the registry of native Go functions tawk exposes
+// to AWK programs, plus support for extending it with plugins.
+package builtins
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default returns tawk's built-in function table. The parser and
+// interpreter configs are both built from this single map so they cannot
+// drift out of sync.
+func Default() map[string]interface{} {
+	return map[string]interface{}{
+		"sum": func(args ...float64) float64 {
+			sum := 0.0
+			for _, a := range args {
+				sum += a
+			}
+			return sum
+		},
+		"repeat":  strings.Repeat,
+		"isodate": func(arg int64) string { return time.UnixMilli(arg).Format(time.RFC3339) },
+	}
+}
+
+// Merge adds every function in from to into, overwriting on name
+// collision.
+func Merge(into, from map[string]interface{}) {
+	for name, fn := range from {
+		into[name] = fn
+	}
+}
+
+// List writes the effective function table to w, one function per line as
+// "name signature", sorted by name.
+func List(w io.Writer, funcs map[string]interface{}) {
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s %s\n", name, reflect.TypeOf(funcs[name]))
+	}
+}
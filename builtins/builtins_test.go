@@ -0,0 +1,45 @@
+package builtins
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDefaultHasExpectedFuncs(t *testing.T) {
+	funcs := Default()
+	for _, name := range []string{"sum", "repeat", "isodate"} {
+		if _, ok := funcs[name]; !ok {
+			t.Errorf("Default() missing %q", name)
+		}
+	}
+}
+
+func TestMergeOverwritesOnCollision(t *testing.T) {
+	into := map[string]interface{}{"sum": "original"}
+	from := map[string]interface{}{"sum": "overridden", "extra": 1}
+
+	Merge(into, from)
+
+	if into["sum"] != "overridden" {
+		t.Errorf("Merge: got sum=%v, want overridden", into["sum"])
+	}
+	if into["extra"] != 1 {
+		t.Errorf("Merge: got extra=%v, want 1", into["extra"])
+	}
+}
+
+func TestListSortsByName(t *testing.T) {
+	funcs := map[string]interface{}{
+		"zeta":  func() {},
+		"alpha": func() {},
+	}
+	var buf bytes.Buffer
+	List(&buf, funcs)
+
+	zetaIdx := strings.Index(buf.String(), "zeta")
+	alphaIdx := strings.Index(buf.String(), "alpha")
+	if alphaIdx == -1 || zetaIdx == -1 || alphaIdx > zetaIdx {
+		t.Errorf("List output not sorted by name: %s", buf.String())
+	}
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+
+package builtins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+)
+
+// Load opens the Go plugin at path and returns the function table exposed
+// through its well-known TawkFuncs symbol.
+func Load(path string) (map[string]interface{}, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading plugin %s: %w", path, err)
+	}
+	sym, err := p.Lookup("TawkFuncs")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	funcs, ok := sym.(*map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: TawkFuncs has type %T, want *map[string]interface{}", path, sym)
+	}
+	return *funcs, nil
+}
+
+// LoadDir loads every .so file under dir and merges their function tables.
+func LoadDir(dir string) (map[string]interface{}, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("scanning plugin dir %s: %w", dir, err)
+	}
+	merged := make(map[string]interface{})
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".so") {
+			continue
+		}
+		funcs, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		Merge(merged, funcs)
+	}
+	return merged, nil
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package builtins
+
+import "fmt"
+
+// Load always fails on windows: the standard library's plugin package is
+// only supported on linux and darwin.
+func Load(path string) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("plugins are not supported on windows")
+}
+
+// LoadDir always fails on windows; see Load.
+func LoadDir(dir string) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("plugins are not supported on windows")
+}
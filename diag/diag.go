@@ -0,0 +1,72 @@
+// Package diag formats tawk's parse and runtime errors for consumption by
+// editors and CI systems, alongside the traditional human-readable caret
+// diagram.
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Severity is the diagnostic's severity level.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic describes a single parse or runtime error, in a form that
+// can be rendered as GNU-style or JSON output. Line, Column, EndLine and
+// EndColumn are 0 when no position is available (as for most runtime
+// errors today), in which case they're omitted from both renderings
+// rather than printed as a misleading ":0:0:".
+type Diagnostic struct {
+	File      string   `json:"file"`
+	Line      int      `json:"line,omitempty"`
+	Column    int      `json:"column,omitempty"`
+	EndLine   int      `json:"endLine,omitempty"`
+	EndColumn int      `json:"endColumn,omitempty"`
+	Severity  Severity `json:"severity"`
+	Message   string   `json:"message"`
+	Source    string   `json:"source"`
+}
+
+// Format is one of "human", "gnu" or "json".
+type Format string
+
+const (
+	Human Format = "human"
+	GNU   Format = "gnu"
+	JSON  Format = "json"
+)
+
+// ParseFormat validates a -error-format flag value.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case Human, GNU, JSON:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("unknown -error-format %q: want human, gnu or json", s)
+	}
+}
+
+// WriteGNU writes d to w as "file:line:col: message", or just
+// "file: message" when d has no position.
+func WriteGNU(w io.Writer, d Diagnostic) error {
+	var err error
+	if d.Line > 0 {
+		_, err = fmt.Fprintf(w, "%s:%d:%d: %s\n", d.File, d.Line, d.Column, d.Message)
+	} else {
+		_, err = fmt.Fprintf(w, "%s: %s\n", d.File, d.Message)
+	}
+	return err
+}
+
+// WriteJSON writes diags to w as a JSON array.
+func WriteJSON(w io.Writer, diags []Diagnostic) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diags)
+}
@@ -0,0 +1,71 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []string{"human", "gnu", "json"} {
+		if _, err := ParseFormat(f); err != nil {
+			t.Errorf("ParseFormat(%q): %v", f, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(\"xml\"): expected an error")
+	}
+}
+
+func TestWriteGNU(t *testing.T) {
+	var buf bytes.Buffer
+	d := Diagnostic{File: "prog.awk", Line: 3, Column: 7, Message: "unexpected token"}
+	if err := WriteGNU(&buf, d); err != nil {
+		t.Fatalf("WriteGNU: %v", err)
+	}
+	want := "prog.awk:3:7: unexpected token\n"
+	if buf.String() != want {
+		t.Errorf("WriteGNU: got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteGNUWithoutPosition(t *testing.T) {
+	var buf bytes.Buffer
+	d := Diagnostic{File: "prog.awk", Message: "division by zero"}
+	if err := WriteGNU(&buf, d); err != nil {
+		t.Fatalf("WriteGNU: %v", err)
+	}
+	want := "prog.awk: division by zero\n"
+	if buf.String() != want {
+		t.Errorf("WriteGNU without a position: got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteJSONOmitsZeroPosition(t *testing.T) {
+	var buf bytes.Buffer
+	diags := []Diagnostic{{File: "prog.awk", Severity: SeverityError, Message: "division by zero", Source: "tawk"}}
+	if err := WriteJSON(&buf, diags); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	for _, field := range []string{`"line"`, `"column"`, `"endLine"`, `"endColumn"`} {
+		if bytes.Contains(buf.Bytes(), []byte(field)) {
+			t.Errorf("WriteJSON without a position: output still contains %s: %s", field, buf.String())
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	diags := []Diagnostic{{File: "prog.awk", Line: 1, Column: 1, Severity: SeverityError, Message: "boom", Source: "tawk"}}
+	if err := WriteJSON(&buf, diags); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	var got []Diagnostic
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJSON produced invalid JSON: %v", err)
+	}
+	if len(got) != 1 || got[0] != diags[0] {
+		t.Errorf("WriteJSON round-trip: got %+v, want %+v", got, diags)
+	}
+}
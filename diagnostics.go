@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/TroutSoftware/awk/diag"
+	"github.com/benhoyt/goawk/lexer"
+	"github.com/benhoyt/goawk/parser"
+)
+
+// reportParseError prints a parser.ParseError in the requested format and
+// exits with status 1.
+func reportParseError(src []byte, file string, perr *parser.ParseError, format diag.Format) {
+	d := diag.Diagnostic{
+		File:      file,
+		Line:      perr.Position.Line,
+		Column:    perr.Position.Column,
+		EndLine:   perr.Position.Line,
+		EndColumn: perr.Position.Column,
+		Severity:  diag.SeverityError,
+		Message:   perr.Message,
+		Source:    "tawk",
+	}
+	reportDiagnostic(src, d, format, perr)
+}
+
+// reportRuntimeError prints an interp.ExecProgram error in the requested
+// format and exits with status 1. goawk's interpreter errors don't carry
+// source positions, so these diagnostics are always reported without a
+// line or column rather than a fabricated one.
+func reportRuntimeError(src []byte, file string, err error, format diag.Format) {
+	d := diag.Diagnostic{
+		File:     file,
+		Severity: diag.SeverityError,
+		Message:  err.Error(),
+		Source:   "tawk",
+	}
+	reportDiagnostic(src, d, format, err)
+}
+
+// reportDiagnostic writes d to stderr in the requested format and exits
+// with status 1. err is the original error d was built from; the default
+// "human" format prints err.Error() in full (which, for parse errors,
+// includes the "parse error at LINE:COL:" prefix alongside the caret
+// diagram) rather than d.Message alone, since the GNU and JSON formats
+// already render that position themselves from d.Line/d.Column.
+func reportDiagnostic(src []byte, d diag.Diagnostic, format diag.Format, err error) {
+	writeDiagnostic(os.Stderr, src, d, format, err)
+	os.Exit(1)
+}
+
+func writeDiagnostic(w io.Writer, src []byte, d diag.Diagnostic, format diag.Format, err error) {
+	switch format {
+	case diag.GNU:
+		diag.WriteGNU(w, d)
+	case diag.JSON:
+		diag.WriteJSON(w, []diag.Diagnostic{d})
+	default:
+		if d.Line > 0 {
+			showSourceLine(w, src, lexer.Position{Line: d.Line, Column: d.Column}, len(err.Error()))
+		}
+		fmt.Fprintln(w, err)
+	}
+}
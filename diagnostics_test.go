@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TroutSoftware/awk/diag"
+	"github.com/benhoyt/goawk/lexer"
+	"github.com/benhoyt/goawk/parser"
+)
+
+func TestWriteDiagnosticHumanFormatShowsFullError(t *testing.T) {
+	src := []byte("BEGIN { x*; }\n")
+	perr := &parser.ParseError{
+		Position: lexer.Position{Line: 1, Column: 11},
+		Message:  "expected expression instead of ;",
+	}
+	d := diag.Diagnostic{
+		File:     "prog.awk",
+		Line:     perr.Position.Line,
+		Column:   perr.Position.Column,
+		Severity: diag.SeverityError,
+		Message:  perr.Message,
+		Source:   "tawk",
+	}
+
+	var buf bytes.Buffer
+	writeDiagnostic(&buf, src, d, diag.Human, perr)
+
+	got := buf.String()
+	if !strings.Contains(got, perr.Error()) {
+		t.Errorf("writeDiagnostic human format: got %q, want it to contain full error %q", got, perr.Error())
+	}
+	if !strings.Contains(got, "^") {
+		t.Errorf("writeDiagnostic human format: got %q, want a caret diagram", got)
+	}
+}
+
+func TestWriteDiagnosticHumanFormatWithoutPosition(t *testing.T) {
+	err := errors.New("division by zero")
+	d := diag.Diagnostic{
+		File:     "prog.awk",
+		Severity: diag.SeverityError,
+		Message:  err.Error(),
+		Source:   "tawk",
+	}
+
+	var buf bytes.Buffer
+	writeDiagnostic(&buf, nil, d, diag.Human, err)
+
+	want := "division by zero\n"
+	if buf.String() != want {
+		t.Errorf("writeDiagnostic human format without a position: got %q, want %q", buf.String(), want)
+	}
+}
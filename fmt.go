@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/TroutSoftware/awk/builtins"
+	"github.com/TroutSoftware/awk/printer"
+	"github.com/benhoyt/goawk/parser"
+)
+
+// runFmt implements the `tawk fmt` subcommand: it parses one or more AWK
+// programs and re-emits them as canonical source, gofmt-style.
+//
+// Known limitation: the parser AST doesn't carry comment nodes yet, so
+// fmt can only round-trip a leading file header comment block (and only
+// with -comments); any other comment in the source makes fmt refuse to
+// reformat that file rather than silently discard it.
+func runFmt(args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	var progFiles multiString
+	fs.Var(&progFiles, "f", "format AWK source from `progfile` (multiple allowed)")
+	tabWidth := fs.Int("tabwidth", 8, "tab width to use when re-indenting output")
+	comments := fs.Bool("comments", false, "preserve the leading file header comment block "+
+		"(other comments aren't supported yet: formatting a file containing them fails)")
+	list := fs.Bool("l", false, "list files whose formatting differs, without rewriting them")
+	write := fs.Bool("w", false, "write result to (rather than stdout) the input progfile(s)")
+	fs.Parse(args)
+
+	files := append([]string{}, []string(progFiles)...)
+	files = append(files, fs.Args()...)
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	cfg := printer.Config{TabWidth: *tabWidth, Comments: *comments}
+	exitCode := 0
+	for _, file := range files {
+		src, err := readFmtSource(file)
+		if err != nil {
+			errorExit("%s", err)
+		}
+
+		parserConfig := &parser.ParserConfig{Funcs: builtins.Default()}
+		prog, err := parser.ParseProgram(src, parserConfig)
+		if err != nil {
+			errMsg := fmt.Sprintf("%s", err)
+			if perr, ok := err.(*parser.ParseError); ok {
+				showSourceLine(os.Stderr, src, perr.Position, len(errMsg))
+			}
+			errorExit("%s", errMsg)
+		}
+
+		changed, err := printer.Diff(src, prog, cfg)
+		if err != nil {
+			errorExit("%s: %s", displayName(file), err)
+		}
+
+		switch {
+		case *list:
+			if changed {
+				fmt.Println(displayName(file))
+				exitCode = 1
+			}
+		case *write:
+			if file == "-" {
+				errorExit("cannot use -w when reading the program from stdin")
+			}
+			if changed {
+				var buf bytes.Buffer
+				if err := printer.Fprint(&buf, src, prog, cfg); err != nil {
+					errorExit("%s", err)
+				}
+				if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+					errorExit("%s", err)
+				}
+			}
+		default:
+			if err := printer.Fprint(os.Stdout, src, prog, cfg); err != nil {
+				errorExit("%s", err)
+			}
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func readFmtSource(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+func displayName(file string) string {
+	if file == "-" {
+		return "<stdin>"
+	}
+	return file
+}
@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"strings"
-	"time"
 	"unicode/utf8"
 
+	"github.com/TroutSoftware/awk/builtins"
+	"github.com/TroutSoftware/awk/diag"
+	"github.com/TroutSoftware/awk/printer"
+	"github.com/TroutSoftware/awk/rewrite"
 	"github.com/benhoyt/goawk/interp"
 	"github.com/benhoyt/goawk/lexer"
 	"github.com/benhoyt/goawk/parser"
@@ -20,6 +24,11 @@ import (
 const version = "1.0"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		runFmt(os.Args[2:])
+		return
+	}
+
 	// Main AWK arguments
 	var progFiles multiString
 	flag.Var(&progFiles, "f", "load AWK source from `progfile` (multiple allowed)")
@@ -34,6 +43,21 @@ func main() {
 	cpuprofile := flag.String("cpuprofile", "", "write CPU profile to `file`")
 	memprofile := flag.String("memprofile", "", "write memory profile to `file`")
 
+	// Rewrite arguments
+	var rewriteRules multiString
+	flag.Var(&rewriteRules, "r", "apply rewrite rule `pattern -> replacement` (multiple allowed)")
+	rewriteComments := flag.Bool("comments", false, "when printing a -r rewrite, preserve the leading file header comment block (other comments are not yet supported; see tawk fmt -comments)")
+
+	// Plugin arguments
+	var plugins multiString
+	flag.Var(&plugins, "plugin", "load native functions from `path.so` (multiple allowed)")
+	var pluginDirs multiString
+	flag.Var(&pluginDirs, "plugin-dir", "load native functions from every .so under `dir` (multiple allowed)")
+	listFuncs := flag.Bool("list-funcs", false, "print the effective function table and exit")
+
+	// Diagnostics arguments
+	errorFormatFlag := flag.String("error-format", "human", "error output `format`: human, gnu or json")
+
 	flag.Parse()
 	args := flag.Args()
 
@@ -42,7 +66,39 @@ func main() {
 		return
 	}
 
+	errorFormat, err := diag.ParseFormat(*errorFormatFlag)
+	if err != nil {
+		errorExit("%s", err)
+	}
+
+	funcs := builtins.Default()
+	for _, path := range plugins {
+		loaded, err := builtins.Load(path)
+		if err != nil {
+			errorExit("%s", err)
+		}
+		builtins.Merge(funcs, loaded)
+	}
+	for _, dir := range pluginDirs {
+		loaded, err := builtins.LoadDir(dir)
+		if err != nil {
+			errorExit("%s", err)
+		}
+		builtins.Merge(funcs, loaded)
+	}
+
+	if *listFuncs {
+		builtins.List(os.Stdout, funcs)
+		return
+	}
+
 	var src []byte
+	progName := "<inline>"
+	if len(progFiles) == 1 {
+		progName = progFiles[0]
+	} else if len(progFiles) > 1 {
+		progName = "<multiple>"
+	}
 	if len(progFiles) > 0 {
 		// Read source: the concatenation of all source files specified
 		buf := &bytes.Buffer{}
@@ -80,44 +136,51 @@ func main() {
 	parserConfig := &parser.ParserConfig{
 		DebugTypes:  *debugTypes,
 		DebugWriter: os.Stderr,
-		Funcs: map[string]interface{}{
-			"sum": func(args ...float64) float64 {
-				sum := 0.0
-				for _, a := range args {
-					sum += a
-				}
-				return sum
-			},
-			"repeat":  strings.Repeat,
-			"isodate": func(arg int64) string { return time.UnixMilli(arg).Format(time.RFC3339) },
-		},
+		Funcs:       funcs,
 	}
+
+	if len(rewriteRules) > 0 {
+		rules := make([]*rewrite.Rule, len(rewriteRules))
+		for i, r := range rewriteRules {
+			rule, err := rewrite.ParseRule(r)
+			if err != nil {
+				errorExit("%s", err)
+			}
+			rules[i] = rule
+		}
+		rewritten, err := rewrite.Apply(src, rules)
+		if err != nil {
+			errorExit("%s", err)
+		}
+		prog, err := parser.ParseProgram(rewritten, parserConfig)
+		if err != nil {
+			if perr, ok := err.(*parser.ParseError); ok {
+				reportParseError(rewritten, progName, perr, errorFormat)
+			}
+			errorExit("%s", err)
+		}
+		if err := printer.Fprint(os.Stdout, rewritten, prog, printer.Config{Comments: *rewriteComments}); err != nil {
+			errorExit("%s", err)
+		}
+		return
+	}
+
 	prog, err := parser.ParseProgram(src, parserConfig)
 	if err != nil {
-		errMsg := fmt.Sprintf("%s", err)
-		if err, ok := err.(*parser.ParseError); ok {
-			showSourceLine(src, err.Position, len(errMsg))
+		if perr, ok := err.(*parser.ParseError); ok {
+			reportParseError(src, progName, perr, errorFormat)
 		}
-		errorExit("%s", errMsg)
+		errorExit("%s", err)
 	}
 	if *debug {
 		fmt.Fprintln(os.Stderr, prog)
 	}
+
 	config := &interp.Config{
 		Argv0: filepath.Base(os.Args[0]),
 		Args:  args,
 		Vars:  []string{"FS", *fieldSep},
-		Funcs: map[string]interface{}{
-			"sum": func(args ...float64) float64 {
-				sum := 0.0
-				for _, a := range args {
-					sum += a
-				}
-				return sum
-			},
-			"repeat":  strings.Repeat,
-			"isodate": func(arg int64) string { return time.UnixMilli(arg).Format(time.RFC3339) },
-		},
+		Funcs: funcs,
 	}
 	for _, v := range vars {
 		parts := strings.SplitN(v, "=", 2)
@@ -139,7 +202,7 @@ func main() {
 
 	status, err := interp.ExecProgram(prog, config)
 	if err != nil {
-		errorExit("%s", err)
+		reportRuntimeError(src, progName, err, errorFormat)
 	}
 
 	if *cpuprofile != "" {
@@ -170,19 +233,19 @@ func main() {
 //
 // -----------------------------------------------------
 // parse error at 1:11: expected expression instead of ;
-func showSourceLine(src []byte, pos lexer.Position, dividerLen int) {
+func showSourceLine(w io.Writer, src []byte, pos lexer.Position, dividerLen int) {
 	divider := strings.Repeat("-", dividerLen)
 	if divider != "" {
-		fmt.Fprintln(os.Stderr, divider)
+		fmt.Fprintln(w, divider)
 	}
 	lines := bytes.Split(src, []byte{'\n'})
 	srcLine := string(lines[pos.Line-1])
 	numTabs := strings.Count(srcLine[:pos.Column-1], "\t")
 	runeColumn := utf8.RuneCountInString(srcLine[:pos.Column-1])
-	fmt.Fprintln(os.Stderr, strings.Replace(srcLine, "\t", "    ", -1))
-	fmt.Fprintln(os.Stderr, strings.Repeat(" ", runeColumn)+strings.Repeat("   ", numTabs)+"^")
+	fmt.Fprintln(w, strings.Replace(srcLine, "\t", "    ", -1))
+	fmt.Fprintln(w, strings.Repeat(" ", runeColumn)+strings.Repeat("   ", numTabs)+"^")
 	if divider != "" {
-		fmt.Fprintln(os.Stderr, divider)
+		fmt.Fprintln(w, divider)
 	}
 }
 
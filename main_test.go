@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildTawk compiles the tawk binary into a temp directory and returns its
+// path, so integration tests can exercise the real main() (flag parsing,
+// the -r wiring, stdout) as a subprocess rather than reimplementing it.
+func buildTawk(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "tawk")
+	cmd := exec.Command("go", "build", "-o", bin, ".")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build tawk: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestMainRewriteFlagRewritesAndPrintsProgram(t *testing.T) {
+	bin := buildTawk(t)
+
+	cmd := exec.Command(bin, "-r", "sum(_a, _b) -> _a + _b", "BEGIN { x = sum(a, b) }")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("tawk -r: %v\nstderr: %s", err, stderr.String())
+	}
+
+	got := stdout.String()
+	if strings.Contains(got, "sum(") {
+		t.Errorf("tawk -r left the call unrewritten: %s", got)
+	}
+	if !strings.Contains(got, "a + b") {
+		t.Errorf("tawk -r did not produce a + b: %s", got)
+	}
+}
+
+func TestMainRewriteFlagReportsBadRule(t *testing.T) {
+	bin := buildTawk(t)
+
+	cmd := exec.Command(bin, "-r", "not a rule", "BEGIN { x = 1 }")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		t.Fatalf("tawk -r with a malformed rule: expected a non-zero exit, got none")
+	}
+	if !strings.Contains(stderr.String(), "->") {
+		t.Errorf("tawk -r with a malformed rule: stderr %q does not mention the expected PATTERN -> REPLACEMENT form", stderr.String())
+	}
+}
@@ -0,0 +1,178 @@
+// Package printer renders a parsed AWK program back to canonical source
+// text, for use by tools such as `tawk fmt`.
+//
+// Known limitation: since the parser AST doesn't carry comment nodes,
+// Fprint can only round-trip a leading file header comment block (and
+// only when Config.Comments is set). Any other comment in the source
+// makes Fprint refuse to reformat rather than silently drop it; see
+// ErrLossyComments.
+package printer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/benhoyt/goawk/parser"
+)
+
+// Config controls how Fprint renders a program.
+type Config struct {
+	TabWidth int  // width to expand leading tabs to; 0 or 8 leaves tabs untouched
+	Comments bool // carry over the leading file header comment block, if any
+}
+
+// ErrLossyComments is returned by Fprint when src contains comments that
+// would be silently discarded by re-emitting prog. The parser AST does
+// not carry comment nodes, so the printer can only round-trip a leading
+// file header block, and only when Config.Comments is set; any other
+// comment in src (or a header with Comments unset) cannot be reproduced.
+var ErrLossyComments = errors.New("printer: source contains comments that would be lost; pass -comments to keep the leading header, or remove other comments before formatting")
+
+// Fprint writes prog to w as canonical AWK source. src is the original
+// program text, used both to recover the leading comment block when
+// Config.Comments is set, and to detect comments Fprint cannot carry
+// over, in which case it returns ErrLossyComments instead of silently
+// dropping them.
+func Fprint(w io.Writer, src []byte, prog *parser.Program, cfg Config) error {
+	header, rest := splitLeadingComments(src)
+	if containsComment(rest) {
+		return ErrLossyComments
+	}
+	if header != "" && !cfg.Comments {
+		return ErrLossyComments
+	}
+
+	if cfg.Comments && header != "" {
+		if _, err := io.WriteString(w, header); err != nil {
+			return err
+		}
+	}
+	out := prog.String()
+	if cfg.TabWidth > 0 && cfg.TabWidth != 8 {
+		out = reindent(out, cfg.TabWidth)
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// splitLeadingComments returns the block of '#' comment lines (and blank
+// lines interleaved with them) at the very start of src, plus the
+// remaining, unconsumed source.
+func splitLeadingComments(src []byte) (header string, rest []byte) {
+	var buf bytes.Buffer
+	consumed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+			consumed += len(line) + 1
+			continue
+		}
+		break
+	}
+	if buf.Len() == 0 {
+		return "", src
+	}
+	return buf.String() + "\n", src[consumed:]
+}
+
+// containsComment reports whether src contains a '#' comment marker
+// outside of a double-quoted string literal or a /regex/ literal. It's a
+// lightweight scan, not a full AWK lexer, but is conservative enough to
+// catch the inline and trailing comments the printer cannot preserve,
+// while not mistaking a regex like /^#/ for one.
+func containsComment(src []byte) bool {
+	inString := false
+	inRegex := false
+	var prev byte
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if inRegex {
+			if c == '\\' {
+				i++ // skip the escaped character
+			} else if c == '/' {
+				inRegex = false
+				prev = c
+			}
+			continue
+		}
+		if inString {
+			if c == '\\' {
+				i++ // skip the escaped character
+			} else if c == '"' {
+				inString = false
+				prev = c
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '/':
+			if regexCanStart(prev) {
+				inRegex = true
+			} else {
+				prev = c
+			}
+		case '#':
+			return true
+		default:
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				prev = c
+			}
+		}
+	}
+	return false
+}
+
+// regexCanStart reports whether a '/' following prev (the last
+// non-whitespace byte seen, or 0 at start of input) begins a regex
+// literal rather than a division operator. Division can only follow an
+// operand (identifier/number character, ')', ']', '$', or a closing
+// quote), so treat '/' as a regex start everywhere else.
+func regexCanStart(prev byte) bool {
+	switch {
+	case prev == 0:
+		return true
+	case prev >= '0' && prev <= '9':
+		return false
+	case prev >= 'a' && prev <= 'z', prev >= 'A' && prev <= 'Z', prev == '_':
+		return false
+	case prev == ')' || prev == ']' || prev == '$':
+		return false
+	default:
+		return true
+	}
+}
+
+// reindent replaces each leading tab in out with width spaces.
+func reindent(out string, width int) string {
+	pad := strings.Repeat(" ", width)
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		j := 0
+		for j < len(line) && line[j] == '\t' {
+			j++
+		}
+		if j > 0 {
+			lines[i] = strings.Repeat(pad, j) + line[j:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff reports whether formatting prog with cfg would change src. It
+// returns ErrLossyComments under the same conditions as Fprint.
+func Diff(src []byte, prog *parser.Program, cfg Config) (bool, error) {
+	var buf bytes.Buffer
+	if err := Fprint(&buf, src, prog, cfg); err != nil {
+		return false, err
+	}
+	return !bytes.Equal(bytes.TrimRight(src, "\n"), bytes.TrimRight(buf.Bytes(), "\n")), nil
+}
@@ -0,0 +1,89 @@
+package printer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/benhoyt/goawk/parser"
+)
+
+func parseOrFatal(t *testing.T, src string) *parser.Program {
+	t.Helper()
+	prog, err := parser.ParseProgram([]byte(src), nil)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", src, err)
+	}
+	return prog
+}
+
+func TestFprintNoComments(t *testing.T) {
+	src := "BEGIN { print 1 }\n"
+	prog := parseOrFatal(t, src)
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, []byte(src), prog, Config{}); err != nil {
+		t.Fatalf("Fprint: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("Fprint produced no output")
+	}
+}
+
+func TestFprintRejectsHeaderWithoutCommentsFlag(t *testing.T) {
+	src := "# a header comment\nBEGIN { print 1 }\n"
+	prog := parseOrFatal(t, src)
+
+	var buf bytes.Buffer
+	err := Fprint(&buf, []byte(src), prog, Config{})
+	if !errors.Is(err, ErrLossyComments) {
+		t.Fatalf("Fprint without -comments: got err %v, want ErrLossyComments", err)
+	}
+}
+
+func TestFprintKeepsHeaderWithCommentsFlag(t *testing.T) {
+	src := "# a header comment\nBEGIN { print 1 }\n"
+	prog := parseOrFatal(t, src)
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, []byte(src), prog, Config{Comments: true}); err != nil {
+		t.Fatalf("Fprint with -comments: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("# a header comment")) {
+		t.Fatalf("Fprint output missing header comment: %q", buf.String())
+	}
+}
+
+func TestFprintRejectsBodyComments(t *testing.T) {
+	src := "BEGIN {\n\tprint 1 # inline comment\n}\n"
+	prog := parseOrFatal(t, src)
+
+	var buf bytes.Buffer
+	err := Fprint(&buf, []byte(src), prog, Config{Comments: true})
+	if !errors.Is(err, ErrLossyComments) {
+		t.Fatalf("Fprint with a body comment: got err %v, want ErrLossyComments", err)
+	}
+}
+
+func TestFprintAllowsRegexWithHash(t *testing.T) {
+	src := "/^#/ { next }\n{ print }\n"
+	prog := parseOrFatal(t, src)
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, []byte(src), prog, Config{}); err != nil {
+		t.Fatalf("Fprint with a /^#/ regex and no comments: %v", err)
+	}
+}
+
+func TestDiffReportsChange(t *testing.T) {
+	src := "BEGIN{print 1}\n"
+	prog := parseOrFatal(t, src)
+
+	changed, err := Diff([]byte(src), prog, Config{})
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if !changed {
+		t.Fatal("Diff: expected unformatted source to be reported as changed")
+	}
+}
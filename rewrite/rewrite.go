@@ -0,0 +1,412 @@
+// Package rewrite implements gofmt-style `-r 'pattern -> replacement'`
+// rewrite rules over AWK source text.
+//
+// goawk's parsed AST lives in an internal package and isn't reachable
+// from outside the goawk module, so rewriting can't walk the parser's
+// expression tree the way gofmt -r walks go/ast. Instead, Apply tokenizes
+// the program with the public lexer package and matches/substitutes
+// directly against that token stream, splicing replacement text into a
+// copy of the original source. This means a rule only sees the tokens
+// goawk's lexer produces outside of regex-literal context (Scan, not
+// ScanRegex; see the regexCanStart limitation in package printer), but
+// it's otherwise enough for the mechanical, call-shaped refactors this
+// flag targets.
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/benhoyt/goawk/lexer"
+)
+
+// Rule is a single pattern/replacement pair. Both sides are AWK
+// expressions whose metavariables (identifiers matching metaVar) stand
+// for "any expression".
+type Rule struct {
+	Pattern        []token
+	Replacement    []token
+	patternSrc     string // translated pattern text; Pattern token offsets are into this
+	replacementSrc string // translated replacement text; Replacement token offsets are into this
+	src            string // original "pattern -> replacement" text, for error messages
+}
+
+// token is a single lexical token, along with the byte range in its
+// owning source (a Rule's pattern/replacement text, or the program being
+// rewritten) that produced it. Punctuation and keyword tokens always
+// spell the same way, so Start/End only matter for comparing or copying
+// NAME, NUMBER, STRING and REGEX tokens verbatim.
+type token struct {
+	Tok        lexer.Token
+	Start, End int
+}
+
+func (t token) text(src []byte) string { return string(src[t.Start:t.End]) }
+
+// metaVar matches identifiers used as pattern metavariables: ASCII names
+// like _a, _b, or the placeholders replaceGreekMetavars substitutes for
+// Greek-letter metavariables like α, β before tokenizing (AWK identifiers
+// are ASCII-only, so a literal Greek letter would never reach here).
+var metaVar = regexp.MustCompile(`^(_[a-z]|_greek[0-9]+)$`)
+
+// greek matches a single Greek-letter metavariable as written by the
+// user in a rewrite rule.
+var greek = regexp.MustCompile(`[α-ω]`)
+
+// ParseRule parses a rule of the form "pattern -> replacement".
+func ParseRule(src string) (*Rule, error) {
+	// The AWK lexer only accepts ASCII identifiers, so Greek-letter
+	// metavariables must be swapped for ASCII placeholders before either
+	// side is tokenized. Both sides of the rule are translated together
+	// so the same Greek letter maps to the same placeholder throughout.
+	translated := replaceGreekMetavars(src)
+
+	parts := strings.SplitN(translated, "->", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rewrite rule %q: expected PATTERN -> REPLACEMENT", src)
+	}
+	patternSrc, replacementSrc := parts[0], parts[1]
+
+	pattern, err := tokenize([]byte(patternSrc))
+	if err != nil {
+		return nil, fmt.Errorf("rewrite rule %q: pattern: %w", src, err)
+	}
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("rewrite rule %q: empty pattern", src)
+	}
+	replacement, err := tokenize([]byte(replacementSrc))
+	if err != nil {
+		return nil, fmt.Errorf("rewrite rule %q: replacement: %w", src, err)
+	}
+	return &Rule{
+		Pattern:        pattern,
+		Replacement:    replacement,
+		patternSrc:     patternSrc,
+		replacementSrc: replacementSrc,
+		src:            src,
+	}, nil
+}
+
+// replaceGreekMetavars rewrites every Greek letter in src to an ASCII
+// placeholder identifier matching metaVar, assigning the same letter the
+// same placeholder wherever it occurs.
+func replaceGreekMetavars(src string) string {
+	placeholders := map[rune]string{}
+	return greek.ReplaceAllStringFunc(src, func(letter string) string {
+		r := []rune(letter)[0]
+		if _, ok := placeholders[r]; !ok {
+			placeholders[r] = fmt.Sprintf("_greek%d", len(placeholders))
+		}
+		return placeholders[r]
+	})
+}
+
+// tokenize lexes src into a flat token list, recording each token's byte
+// range so its text can be sliced straight out of src rather than
+// re-rendered.
+func tokenize(src []byte) ([]token, error) {
+	lineStarts := []int{0}
+	for i, b := range src {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offsetOf := func(pos lexer.Position) int {
+		return lineStarts[pos.Line-1] + pos.Column - 1
+	}
+
+	var toks []token
+	lx := lexer.NewLexer(src)
+	for {
+		pos, tok, val := lx.Scan()
+		if tok == lexer.NEWLINE {
+			continue
+		}
+		if tok == lexer.EOF {
+			break
+		}
+		if tok == lexer.ILLEGAL {
+			return nil, fmt.Errorf("%s: %s", pos, val)
+		}
+		start := offsetOf(pos)
+		toks = append(toks, token{Tok: tok, Start: start, End: start + tokenLen(src, start, tok, val)})
+	}
+	return toks, nil
+}
+
+// tokenLen returns how many bytes of src starting at start make up tok,
+// given the value Scan returned for it (used directly for NAME and
+// NUMBER, which Scan already reports as a raw source slice; recomputed
+// for STRING, whose Scan value has quotes stripped and escapes
+// processed, so is shorter than the source text it came from).
+func tokenLen(src []byte, start int, tok lexer.Token, val string) int {
+	switch tok {
+	case lexer.NAME, lexer.NUMBER:
+		return len(val)
+	case lexer.STRING:
+		return stringLiteralLen(src, start)
+	default:
+		return len(tok.String())
+	}
+}
+
+func stringLiteralLen(src []byte, start int) int {
+	quote := src[start]
+	i := start + 1
+	for i < len(src) {
+		if src[i] == '\\' && i+1 < len(src) {
+			i += 2
+			continue
+		}
+		end := src[i] == quote
+		i++
+		if end {
+			break
+		}
+	}
+	return i - start
+}
+
+// exprStart reports whether tok can begin an AWK expression. Metavariable
+// matches are restricted to start on one of these, so a bare metavariable
+// pattern like "_a" can't match punctuation or statement keywords such as
+// "{" or "BEGIN".
+func exprStart(tok lexer.Token) bool {
+	switch tok {
+	case lexer.NAME, lexer.NUMBER, lexer.STRING, lexer.REGEX,
+		lexer.LPAREN, lexer.DOLLAR, lexer.NOT, lexer.SUB, lexer.ADD,
+		lexer.INCR, lexer.DECR, lexer.GETLINE:
+		return true
+	}
+	return tok >= lexer.FIRST_FUNC && tok <= lexer.LAST_FUNC
+}
+
+func metaVarName(tplSrc []byte, t token) (string, bool) {
+	if t.Tok != lexer.NAME {
+		return "", false
+	}
+	name := t.text(tplSrc)
+	if metaVar.MatchString(name) {
+		return name, true
+	}
+	return "", false
+}
+
+// binding records the program-source byte range a metavariable matched.
+type binding struct{ Start, End int }
+
+// maxRewritesPerNode bounds how many times rules may fire in a row on a
+// single matched span. It guards against rules whose replacement can
+// structurally re-match its own pattern (e.g. `_a -> f(_a)`), which would
+// otherwise grow that span forever: once the cap is hit, or a span
+// reappears unchanged partway through, it hasn't reached a fixed point,
+// and Apply reports that as an error instead of returning a
+// partially-rewritten program.
+const maxRewritesPerNode = 64
+
+// Apply rewrites src to fixed point, scanning left to right and trying
+// each rule in order at every token position. On a match, the matched
+// span is rewritten to its own local fixed point (trying all rules
+// against it again, repeatedly, the same way goawk's resolver-independent
+// span works below) before the scan resumes immediately after it, so
+// rewrites produced by one match are never rescanned as part of the outer
+// left-to-right sweep. If any span fails to reach a fixed point, Apply
+// stops and returns an error rather than leaving a partially-rewritten
+// program in place.
+func Apply(src []byte, rules []*Rule) ([]byte, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	lastEnd := 0
+	for i := 0; i < len(toks); {
+		end, ok := matchStart(src, toks, i, rules)
+		if !ok {
+			i++
+			continue
+		}
+		rewritten, err := rewriteToFixedPoint(src[toks[i].Start:toks[end-1].End], rules)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(src[lastEnd:toks[i].Start])
+		out.Write(rewritten)
+		lastEnd = toks[end-1].End
+		i = end
+	}
+	out.Write(src[lastEnd:])
+	return out.Bytes(), nil
+}
+
+// matchStart tries each rule's pattern starting exactly at toks[i], in
+// order, and returns the index of the first token after the first rule
+// that matches.
+func matchStart(progSrc []byte, toks []token, i int, rules []*Rule) (int, bool) {
+	for _, rule := range rules {
+		binds := map[string]binding{}
+		patSrc := []byte(rule.patternSrc)
+		if end, ok := matchSeq(patSrc, rule.Pattern, 0, progSrc, toks, i, binds, false); ok {
+			return end, true
+		}
+	}
+	return 0, false
+}
+
+// rewriteToFixedPoint repeatedly matches node as a whole against every
+// rule, substituting on the first match, until no rule applies (a fixed
+// point) or maxRewritesPerNode is reached without converging.
+func rewriteToFixedPoint(node []byte, rules []*Rule) ([]byte, error) {
+	cur := node
+	seen := map[string]bool{}
+	for i := 0; i < maxRewritesPerNode; i++ {
+		sig := string(cur)
+		if seen[sig] {
+			return nil, fmt.Errorf("rewrite rule did not converge: %q recurs without reaching a fixed point", sig)
+		}
+		seen[sig] = true
+
+		toks, err := tokenize(cur)
+		if err != nil {
+			return nil, err
+		}
+		applied := false
+		for _, rule := range rules {
+			binds := map[string]binding{}
+			patSrc := []byte(rule.patternSrc)
+			if _, ok := matchSeq(patSrc, rule.Pattern, 0, cur, toks, 0, binds, true); ok {
+				// Rewrite what each metavariable captured before splicing it
+				// in, so a match against sum(length(s), b) also rewrites
+				// length(s) via another rule, rather than copying it in
+				// verbatim because it sat inside this match's span.
+				texts := make(map[string]string, len(binds))
+				for name, b := range binds {
+					if b.Start == 0 && b.End == len(cur) {
+						// This binding captured the whole node (e.g. a bare
+						// `_a -> f(_a)` rule matching all of cur), not a
+						// proper sub-expression of it. Recursing here would
+						// just repeat this same step forever instead of
+						// converging; the enclosing loop already handles
+						// that case via maxRewritesPerNode and seen.
+						texts[name] = string(cur[b.Start:b.End])
+						continue
+					}
+					rewritten, err := Apply(cur[b.Start:b.End], rules)
+					if err != nil {
+						return nil, err
+					}
+					texts[name] = string(rewritten)
+				}
+				cur = []byte(render(rule, texts))
+				applied = true
+				break
+			}
+		}
+		if !applied {
+			return cur, nil
+		}
+	}
+	return nil, fmt.Errorf("rewrite rule did not converge after %d rewrites on %s (its replacement may re-match its own pattern)", maxRewritesPerNode, node)
+}
+
+// matchSeq matches pattern[pi:] (tokenized from patSrc) against toks
+// (tokenized from progSrc) starting at ni, recording metavariable
+// bindings in binds, which is mutated in place and restored on
+// backtracking. It returns the index of the first token after the match
+// once the whole pattern is consumed. If full is set, the match must also
+// consume every remaining token in toks; otherwise a prefix match
+// succeeds as soon as the pattern is exhausted.
+func matchSeq(patSrc []byte, pattern []token, pi int, progSrc []byte, toks []token, ni int, binds map[string]binding, full bool) (int, bool) {
+	if pi == len(pattern) {
+		return ni, !full || ni == len(toks)
+	}
+	if name, ok := metaVarName(patSrc, pattern[pi]); ok {
+		if ni >= len(toks) || !exprStart(toks[ni].Tok) {
+			return 0, false
+		}
+		depth := 0
+		for j := ni; j <= len(toks); j++ {
+			if depth == 0 && j > ni {
+				cand := binding{toks[ni].Start, toks[j-1].End}
+				prev, hadPrev := binds[name]
+				matches := true
+				if hadPrev {
+					matches = string(progSrc[prev.Start:prev.End]) == string(progSrc[cand.Start:cand.End])
+				}
+				if matches {
+					binds[name] = cand
+					if end, ok := matchSeq(patSrc, pattern, pi+1, progSrc, toks, j, binds, full); ok {
+						return end, true
+					}
+				}
+				if hadPrev {
+					binds[name] = prev
+				} else {
+					delete(binds, name)
+				}
+			}
+			if j < len(toks) {
+				switch toks[j].Tok {
+				case lexer.LPAREN, lexer.LBRACKET:
+					depth++
+				case lexer.RPAREN, lexer.RBRACKET:
+					depth--
+				}
+				if depth < 0 {
+					break
+				}
+			}
+		}
+		return 0, false
+	}
+
+	if ni >= len(toks) || !tokensEqual(patSrc, pattern[pi], progSrc, toks[ni]) {
+		return 0, false
+	}
+	return matchSeq(patSrc, pattern, pi+1, progSrc, toks, ni+1, binds, full)
+}
+
+// tokensEqual reports whether two literal (non-metavariable) tokens from
+// different sources match. Punctuation and keyword tokens spell the same
+// way everywhere, so comparing Tok is enough; NAME, NUMBER, STRING and
+// REGEX tokens must also match by text.
+func tokensEqual(aSrc []byte, a token, bSrc []byte, b token) bool {
+	if a.Tok != b.Tok {
+		return false
+	}
+	switch a.Tok {
+	case lexer.NAME, lexer.NUMBER, lexer.STRING, lexer.REGEX:
+		return a.text(aSrc) == b.text(bSrc)
+	default:
+		return true
+	}
+}
+
+// render renders rule.Replacement as source text, substituting each
+// metavariable with the text it was bound to (texts[name], already
+// rewritten to fixed point by the caller). Tokens are joined with a
+// single space, which is always lexically safe (if occasionally more
+// spaced out than a human would write), since the rendered text is
+// immediately re-parsed rather than compared against a golden source.
+func render(rule *Rule, texts map[string]string) string {
+	tplSrc := []byte(rule.replacementSrc)
+	parts := make([]string, 0, len(rule.Replacement))
+	for _, t := range rule.Replacement {
+		if name, ok := metaVarName(tplSrc, t); ok {
+			if text, bound := texts[name]; bound {
+				parts = append(parts, text)
+				continue
+			}
+			// A replacement metavariable with no corresponding pattern
+			// binding; keep its placeholder name rather than panicking,
+			// so the problem is visible in the (invalid) output.
+			parts = append(parts, name)
+			continue
+		}
+		parts = append(parts, t.text(tplSrc))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,133 @@
+package rewrite
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustRule(t *testing.T, src string) *Rule {
+	t.Helper()
+	rule, err := ParseRule(src)
+	if err != nil {
+		t.Fatalf("ParseRule(%q): %v", src, err)
+	}
+	return rule
+}
+
+func TestApplyRewritesCallArguments(t *testing.T) {
+	rule := mustRule(t, "sum(_a, _b) -> _a + _b")
+
+	got, err := Apply([]byte("BEGIN { x = sum(a, b) }"), []*Rule{rule})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if strings.Contains(string(got), "sum(") {
+		t.Fatalf("Apply left the call unrewritten: %s", got)
+	}
+	if !strings.Contains(string(got), "a + b") {
+		t.Fatalf("Apply did not produce a + b: %s", got)
+	}
+}
+
+func TestApplyGreekMetavar(t *testing.T) {
+	rule := mustRule(t, "length(α) -> NF")
+
+	got, err := Apply([]byte("BEGIN { x = length(s) }"), []*Rule{rule})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if strings.Contains(string(got), "length(") || !strings.Contains(string(got), "NF") {
+		t.Fatalf("Apply did not rewrite length(s) to NF: %s", got)
+	}
+}
+
+func TestApplyMatchesLiteralIdentifierAgainstOtherGlobals(t *testing.T) {
+	// total is an ordinary identifier, not a metavariable, so it must be
+	// matched by name wherever it occurs in the program, regardless of
+	// what else the program declares around it.
+	rule := mustRule(t, "total -> sum")
+
+	got, err := Apply([]byte("BEGIN { count = 0; total = 0; x = total }"), []*Rule{rule})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if strings.Contains(string(got), "total") || !strings.Contains(string(got), "sum") {
+		t.Fatalf("Apply did not rewrite the literal identifier total to sum: %s", got)
+	}
+}
+
+func TestApplyDoesNotMatchDifferentIdentifier(t *testing.T) {
+	rule := mustRule(t, "total -> sum")
+	before := []byte("BEGIN { count = 0; count = count }")
+
+	got, err := Apply(before, []*Rule{rule})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if string(got) != string(before) {
+		t.Fatalf("Apply rewrote an unrelated identifier: got %q, want unchanged %q", got, before)
+	}
+}
+
+func TestApplyPreservesUnmatchedFormatting(t *testing.T) {
+	rule := mustRule(t, "sum(_a, _b) -> _a + _b")
+	src := []byte("# a header comment\nBEGIN {\n\tx = sum(a, b) # trailing\n}\n")
+
+	got, err := Apply(src, []*Rule{rule})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if !strings.Contains(string(got), "# a header comment") || !strings.Contains(string(got), "# trailing") {
+		t.Fatalf("Apply lost text outside the matched span: %s", got)
+	}
+}
+
+func TestApplyRewritesMetavariableArguments(t *testing.T) {
+	// A metavariable's captured text is itself a program fragment, so a
+	// second rule must still be able to rewrite it, even though it only
+	// appears as an argument inside the first rule's match.
+	sumRule := mustRule(t, "sum(_a, _b) -> _a + _b")
+	lengthRule := mustRule(t, "length(α) -> NF")
+
+	got, err := Apply([]byte("BEGIN { x = sum(length(s), b) }"), []*Rule{sumRule, lengthRule})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if strings.Contains(string(got), "length(") || strings.Contains(string(got), "sum(") {
+		t.Fatalf("Apply left a nested call unrewritten: %s", got)
+	}
+	if !strings.Contains(string(got), "NF + b") {
+		t.Fatalf("Apply did not produce NF + b: %s", got)
+	}
+}
+
+func TestApplyReportsNonConvergence(t *testing.T) {
+	// _a matches any expression, including f(_a) itself, so a naive
+	// fixed-point loop would grow f(f(f(...))) forever.
+	rule := mustRule(t, "_a -> f(_a)")
+	before := []byte("BEGIN { x = y }")
+
+	type result struct {
+		out []byte
+		err error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		out, err := Apply(before, []*Rule{rule})
+		resCh <- result{out, err}
+	}()
+
+	select {
+	case res := <-resCh:
+		if res.err == nil {
+			t.Fatalf("Apply: expected a non-convergence error, got %s", res.out)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Apply did not terminate on a self-matching rule")
+	}
+}